@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Boolean states for the atomic flags used on QueueOutboundElement.
+const (
+	AtomicFalse int32 = 0
+	AtomicTrue  int32 = 1
+)
+
+// Transport message framing, mirrored from the noise transport format.
+const (
+	MaxMessageSize             = 1 << 16
+	MessageTransportHeaderSize = 16
+	MessageTransportType       = 4
+	MessageKeepaliveSize       = MessageTransportHeaderSize + chacha20poly1305.Overhead
+	PaddingMultiple            = 16
+	MaxContentSize             = MaxMessageSize - MessageTransportHeaderSize - chacha20poly1305.Overhead
+)
+
+// Byte offsets of the destination address field within an IPv4/IPv6 header.
+const (
+	IPv4offsetDst = 16
+	IPv6offsetDst = 24
+)
+
+// QueueOutboundSize bounds how many outbound elements a peer's nonce/
+// outbound queues hold before addToOutboundQueue starts evicting the
+// oldest one.
+const QueueOutboundSize = 1024
+
+// Nonce/handshake lifetime limits.
+const RejectAfterMessages = 1<<64 - 1<<13 - 1
+
+var RejectAfterTime = 180 * time.Second
+
+// changeNetworkReason identifies why changeNetwork was invoked.
+type changeNetworkReason int
+
+// Endpoint indicates the change was triggered by a peer endpoint needing
+// re-resolution after a failed send.
+const Endpoint changeNetworkReason = iota
+
+// changeNetwork is the hook for re-resolving the device's network state
+// for the given reason. Its body is empty in this package: it rebinds no
+// socket and re-resolves no endpoint.
+func changeNetwork(device *Device, reason changeNetworkReason) {}
+
+// signalSend performs a non-blocking send on a signal channel; a pending
+// signal is sufficient; duplicates are coalesced.
+func signalSend(signal chan struct{}) {
+	select {
+	case signal <- struct{}{}:
+	default:
+	}
+}
+
+// sendStatus is the hook for reporting a device-level status code to the
+// host application. Its body is empty in this package: nothing is reported.
+func sendStatus(code int) {}
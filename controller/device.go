@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// defaultBatchSize is used until SetBatchSize overrides it.
+const defaultBatchSize = 128
+
+// netConfig holds the device's UDP socket. mutex is held for reading by
+// senders and the receiver; nothing in this package takes it for writing
+// (changeNetwork, the rebind hook, is empty).
+type netConfig struct {
+	mutex sync.RWMutex
+	conn  interface{} // *ipv4.PacketConn or *ipv6.PacketConn once bound
+}
+
+type devicePool struct {
+	messageBuffers sync.Pool
+}
+
+type deviceQueue struct {
+	encryption chan *QueueOutboundElement
+}
+
+type deviceSignal struct {
+	stop chan struct{}
+}
+
+// indexTable maps the local index we handed a peer during handshake back
+// to that peer, so an incoming transport packet's receiver field can be
+// resolved to the peer (and key pair) it belongs to.
+type indexTable struct {
+	mutex sync.RWMutex
+	peers map[uint32]*Peer
+}
+
+// Lookup returns the peer bound to index, or nil.
+func (it *indexTable) Lookup(index uint32) *Peer {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return it.peers[index]
+}
+
+// bind associates index with peer. Called once a handshake completes and
+// assigns peer a local index; unused until handshake establishment is
+// implemented.
+func (it *indexTable) bind(index uint32, peer *Peer) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if it.peers == nil {
+		it.peers = make(map[uint32]*Peer)
+	}
+	it.peers[index] = peer
+}
+
+type deviceTun struct {
+	device interface {
+		Read(p []byte) (int, error)
+	}
+	mtu int32
+
+	// segmentedReads enables the GRO-style read path (see
+	// Device.routineReadSegmentedFromTUN) for TUN drivers that can return
+	// more than one packet per Read. Leave unset on drivers that only
+	// ever return a single packet per read.
+	segmentedReads bool
+}
+
+// deviceStats counts send-pipeline backpressure and errors.
+type deviceStats struct {
+	droppedNonceQueue      uint64
+	droppedEncryptionQueue uint64
+	droppedOutboundQueue   uint64
+	sendErrors             uint64
+}
+
+// Device owns a single TUN interface and UDP socket, and dispatches
+// packets to/from the peers in routingTable.
+type Device struct {
+	net          netConfig
+	pool         devicePool
+	queue        deviceQueue
+	signal       deviceSignal
+	tun          deviceTun
+	routingTable routingTable
+	indexTable   indexTable
+	batchSize    int32 // atomic; 0 means defaultBatchSize
+	stats        deviceStats
+}
+
+// PutMessageBuffer returns a message buffer to the device's pool for reuse.
+func (device *Device) PutMessageBuffer(buffer *[MaxMessageSize]byte) {
+	device.pool.messageBuffers.Put(buffer)
+}
+
+// BatchSize returns how many outbound elements RoutineSequentialSender
+// will coalesce into a single WriteBatch syscall.
+func (device *Device) BatchSize() int {
+	if n := atomic.LoadInt32(&device.batchSize); n > 0 {
+		return int(n)
+	}
+	return defaultBatchSize
+}
+
+// SetBatchSize overrides the outbound batch size; n <= 0 resets it to the
+// default.
+func (device *Device) SetBatchSize(n int) {
+	atomic.StoreInt32(&device.batchSize, int32(n))
+}
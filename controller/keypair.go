@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// sealer is the subset of chacha20poly1305.AEAD used to encrypt transport
+// packets.
+type sealer interface {
+	Seal(dst, nonce, plaintext, additionalData []byte) []byte
+}
+
+// opener is the subset of chacha20poly1305.AEAD used to decrypt and
+// authenticate transport packets.
+type opener interface {
+	Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// KeyPair holds the sending/receiving ciphers and nonce counter negotiated
+// by a single noise handshake.
+type KeyPair struct {
+	created     time.Time
+	sendNonce   uint64
+	remoteIndex uint32
+	send        sealer
+	open        opener
+}
+
+// KeyPairs tracks the current key pair for a peer. Previous/next key pairs
+// during a rekey are not held.
+type KeyPairs struct {
+	mutex   sync.RWMutex
+	current *KeyPair
+}
+
+// Current returns the key pair in use for new outbound packets, or nil if
+// no handshake has completed yet.
+func (kp *KeyPairs) Current() *KeyPair {
+	kp.mutex.RLock()
+	defer kp.mutex.RUnlock()
+	return kp.current
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+type peerQueue struct {
+	nonce    chan *QueueOutboundElement
+	outbound chan *QueueOutboundElement
+}
+
+// peerStats counts outbound pipeline activity for a single peer.
+type peerStats struct {
+	sendmmsgSegments uint64
+}
+
+type peerSignal struct {
+	stop            chan struct{}
+	newKeyPair      chan struct{}
+	flushNonceQueue chan struct{}
+	handshakeReset  chan struct{}
+	handshakeBegin  chan struct{}
+}
+
+// Peer is one entry in the device's routing table: its current endpoint,
+// key material, and the per-peer queues/goroutines that carry its
+// outbound traffic.
+type Peer struct {
+	device   *Device
+	mutex    sync.RWMutex
+	endpoint *PeerEndpoint
+	queue    peerQueue
+	signal   peerSignal
+	keyPairs KeyPairs
+	stats    peerStats
+
+	// persistentKeepaliveInterval is the configured keepalive period, in
+	// seconds; 0 disables RoutinePersistentKeepAlive's timer.
+	persistentKeepaliveInterval uint32
+
+	sendBackoff peerSendBackoff
+}
+
+// NewPeer creates a peer owned by device and wires up its queues and
+// signal channels. initialEndpoint, if non-nil, seeds the peer's endpoint
+// from configuration (the UAPI "endpoint" field) so sends can succeed
+// before any packet has been received from this peer; otherwise the
+// endpoint stays unset until the receive path learns it via
+// setEndpointFromSource.
+func (device *Device) NewPeer(initialEndpoint *net.UDPAddr) *Peer {
+	peer := &Peer{
+		device: device,
+		queue: peerQueue{
+			nonce:    make(chan *QueueOutboundElement, QueueOutboundSize),
+			outbound: make(chan *QueueOutboundElement, QueueOutboundSize),
+		},
+		signal: peerSignal{
+			stop:            make(chan struct{}),
+			newKeyPair:      make(chan struct{}, 1),
+			flushNonceQueue: make(chan struct{}, 1),
+			handshakeReset:  make(chan struct{}, 1),
+			handshakeBegin:  make(chan struct{}, 1),
+		},
+	}
+
+	if initialEndpoint != nil {
+		peer.endpoint = &PeerEndpoint{Dst: *initialEndpoint}
+	}
+
+	return peer
+}
+
+// String identifies the peer in log output.
+func (peer *Peer) String() string {
+	return "peer"
+}
+
+// SendmmsgSegments returns how many outbound segments have been sent to
+// this peer via the batched sendmmsg path, for observability.
+func (peer *Peer) SendmmsgSegments() uint64 {
+	return atomic.LoadUint64(&peer.stats.sendmmsgSegments)
+}
+
+// PersistentKeepaliveInterval returns the configured keepalive period, in
+// seconds; 0 means RoutinePersistentKeepAlive's timer is disabled.
+func (peer *Peer) PersistentKeepaliveInterval() uint32 {
+	return atomic.LoadUint32(&peer.persistentKeepaliveInterval)
+}
+
+// SetPersistentKeepaliveInterval configures the keepalive period, in
+// seconds; 0 disables RoutinePersistentKeepAlive's timer.
+func (peer *Peer) SetPersistentKeepaliveInterval(seconds uint32) {
+	atomic.StoreUint32(&peer.persistentKeepaliveInterval, seconds)
+}
+
+// KeepKeyFreshSending is the hook, called after each sent batch, for
+// starting a new handshake when the current key pair nears its
+// message/time limits. Its body is empty in this package.
+func (peer *Peer) KeepKeyFreshSending() {}
+
+// TimerAnyAuthenticatedPacketTraversal is the hook called for every
+// authenticated packet (data or keepalive) sent to this peer. Its body is
+// empty in this package.
+func (peer *Peer) TimerAnyAuthenticatedPacketTraversal() {}
+
+// TimerDataSent is the hook called for every data (non-keepalive) packet
+// sent to this peer. Its body is empty in this package; it does not feed
+// RoutinePersistentKeepAlive, which runs on its own fixed interval.
+func (peer *Peer) TimerDataSent() {}
@@ -0,0 +1,22 @@
+package controller
+
+import "testing"
+
+func TestSetPersistentKeepaliveInterval(t *testing.T) {
+	device := &Device{}
+	peer := device.NewPeer(nil)
+
+	if got := peer.PersistentKeepaliveInterval(); got != 0 {
+		t.Fatalf("default PersistentKeepaliveInterval = %d, want 0", got)
+	}
+
+	peer.SetPersistentKeepaliveInterval(25)
+	if got := peer.PersistentKeepaliveInterval(); got != 25 {
+		t.Fatalf("PersistentKeepaliveInterval() = %d, want 25", got)
+	}
+
+	peer.SetPersistentKeepaliveInterval(0)
+	if got := peer.PersistentKeepaliveInterval(); got != 0 {
+		t.Fatalf("PersistentKeepaliveInterval() = %d, want 0 after reset", got)
+	}
+}
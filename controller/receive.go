@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"bt/logger"
+)
+
+/* handleDecryptedPacket is the tail end of the inbound decrypt path: once
+ * a transport packet has been opened and authenticated for peer, this
+ * refreshes the peer's endpoint from the packet's source (roaming) before
+ * the plaintext is handed off to the TUN writer.
+ *
+ * cm4/cm6 carry the local address/interface the packet arrived on; pass
+ * whichever matches the socket family in use, nil for the other.
+ */
+func (device *Device) handleDecryptedPacket(peer *Peer, src *net.UDPAddr, cm4 *ipv4.ControlMessage, cm6 *ipv6.ControlMessage) {
+	peer.setEndpointFromSource(src, cm4, cm6)
+}
+
+// handleIncomingPacket authenticates a raw transport packet received from
+// src and, on success, updates the owning peer's endpoint and returns the
+// decrypted plaintext. It returns nil (and drops the packet) if the
+// receiver index doesn't match a known peer, the peer has no current key
+// pair, or authentication fails.
+func (device *Device) handleIncomingPacket(packet []byte, src *net.UDPAddr, cm4 *ipv4.ControlMessage, cm6 *ipv6.ControlMessage) []byte {
+	if len(packet) < MessageTransportHeaderSize {
+		return nil
+	}
+
+	receiverIndex := binary.LittleEndian.Uint32(packet[4:8])
+	peer := device.indexTable.Lookup(receiverIndex)
+	if peer == nil {
+		return nil
+	}
+
+	keyPair := peer.keyPairs.Current()
+	if keyPair == nil || keyPair.open == nil {
+		return nil
+	}
+
+	var nonce [chacha20poly1305.NonceSize]byte
+	copy(nonce[4:], packet[8:16])
+
+	plaintext, err := keyPair.open.Open(nil, nonce[:], packet[MessageTransportHeaderSize:], nil)
+	if err != nil {
+		logger.Wlog.SaveDebugLog("Failed to authenticate incoming packet:" + err.Error())
+		return nil
+	}
+
+	device.handleDecryptedPacket(peer, src, cm4, cm6)
+	return plaintext
+}
+
+// readIncoming reads one packet off device's UDP socket, returning its
+// source address and whichever of cm4/cm6 matches the socket's address
+// family (the other is left nil). Mirrors writeTo's conn-type switch.
+func readIncoming(device *Device, buffer []byte) (int, *net.UDPAddr, *ipv4.ControlMessage, *ipv6.ControlMessage, error) {
+	device.net.mutex.RLock()
+	conn := device.net.conn
+	device.net.mutex.RUnlock()
+
+	switch conn := conn.(type) {
+	case nil:
+		return 0, nil, nil, nil, errors.New("No UDP socket for device")
+
+	case *ipv4.PacketConn:
+		n, cm, addr, err := conn.ReadFrom(buffer)
+		src, _ := addr.(*net.UDPAddr)
+		return n, src, cm, nil, err
+
+	case *ipv6.PacketConn:
+		n, cm, addr, err := conn.ReadFrom(buffer)
+		src, _ := addr.(*net.UDPAddr)
+		return n, src, nil, cm, err
+
+	default:
+		return 0, nil, nil, nil, errors.New("Unsupported UDP socket type for device")
+	}
+}
+
+/* Reads raw transport packets from the UDP socket, authenticates them
+ * against the peer the receiver index resolves to, and hands the
+ * decrypted plaintext to the TUN device.
+ *
+ * Obs. Single instance per device.
+ */
+func (device *Device) RoutineReceiveIncoming() {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Wlog.SaveErrLog(fmt.Sprintln("recover RoutineReceiveIncoming err:", err))
+		}
+	}()
+
+	logger.Wlog.SaveDebugLog("Routine, incoming receiver, started")
+
+	buffer := make([]byte, MaxMessageSize)
+
+	for {
+		select {
+		case <-device.signal.stop:
+			logger.Wlog.SaveDebugLog("Routine, incoming receiver, stopped")
+			return
+
+		default:
+			n, src, cm4, cm6, err := readIncoming(device, buffer)
+			if err != nil {
+				logger.Wlog.SaveErrLog("Failed to read packet from UDP socket:" + err.Error())
+				return
+			}
+
+			plaintext := device.handleIncomingPacket(buffer[:n], src, cm4, cm6)
+			if plaintext == nil {
+				continue
+			}
+
+			if w, ok := device.tun.device.(io.Writer); ok {
+				if _, err := w.Write(plaintext); err != nil {
+					logger.Wlog.SaveErrLog("Failed to write decrypted packet to TUN device:" + err.Error())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeOpener lets tests control authentication success/failure without a
+// real chacha20poly1305 cipher.
+type fakeOpener struct {
+	plaintext []byte
+	err       error
+}
+
+func (o fakeOpener) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	return o.plaintext, nil
+}
+
+func newTransportPacket(receiverIndex uint32, nonce uint64, ciphertext []byte) []byte {
+	packet := make([]byte, MessageTransportHeaderSize+len(ciphertext))
+	binary.LittleEndian.PutUint32(packet[4:8], receiverIndex)
+	binary.LittleEndian.PutUint64(packet[8:16], nonce)
+	copy(packet[MessageTransportHeaderSize:], ciphertext)
+	return packet
+}
+
+func TestHandleIncomingPacketAuthenticatesAndRoamsEndpoint(t *testing.T) {
+	device := &Device{}
+	peer := device.NewPeer(nil)
+	peer.keyPairs.current = &KeyPair{open: fakeOpener{plaintext: []byte("hello")}}
+	device.indexTable.bind(7, peer)
+
+	src := &net.UDPAddr{IP: net.ParseIP("203.0.113.9"), Port: 51820}
+	packet := newTransportPacket(7, 1, []byte("ciphertext"))
+
+	plaintext := device.handleIncomingPacket(packet, src, nil, nil)
+	if string(plaintext) != "hello" {
+		t.Fatalf("plaintext = %q, want %q", plaintext, "hello")
+	}
+
+	peer.mutex.RLock()
+	endpoint := peer.endpoint
+	peer.mutex.RUnlock()
+
+	if endpoint == nil || endpoint.Dst.String() != src.String() {
+		t.Fatalf("expected endpoint to roam to %v, got %v", src, endpoint)
+	}
+}
+
+func TestHandleIncomingPacketDropsUnknownIndex(t *testing.T) {
+	device := &Device{}
+	packet := newTransportPacket(99, 1, []byte("ciphertext"))
+
+	if got := device.handleIncomingPacket(packet, nil, nil, nil); got != nil {
+		t.Fatalf("expected nil plaintext for unbound receiver index, got %q", got)
+	}
+}
+
+func TestHandleIncomingPacketDropsFailedAuthentication(t *testing.T) {
+	device := &Device{}
+	peer := device.NewPeer(nil)
+	peer.keyPairs.current = &KeyPair{open: fakeOpener{err: errors.New("authentication failed")}}
+	device.indexTable.bind(3, peer)
+
+	packet := newTransportPacket(3, 1, []byte("ciphertext"))
+	if got := device.handleIncomingPacket(packet, nil, nil, nil); got != nil {
+		t.Fatalf("expected nil plaintext for failed authentication, got %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package controller
+
+import "sync"
+
+// routingTable maps a peer's allowed destination IPs back to the peer, so
+// the TUN reader can decide which peer an outbound packet belongs to.
+type routingTable struct {
+	mutex sync.RWMutex
+	ipv4  map[string]*Peer
+	ipv6  map[string]*Peer
+}
+
+// LookupIPv4 returns the peer registered under exactly the address dst, or
+// nil; no prefix (CIDR) matching is done.
+func (rt *routingTable) LookupIPv4(dst []byte) *Peer {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+	return rt.ipv4[string(dst)]
+}
+
+// LookupIPv6 returns the peer registered under exactly the address dst, or
+// nil; no prefix (CIDR) matching is done.
+func (rt *routingTable) LookupIPv6(dst []byte) *Peer {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+	return rt.ipv6[string(dst)]
+}
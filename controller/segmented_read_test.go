@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"net"
+	"testing"
+)
+
+// buildIPv4Packet returns a minimal (header-only-plus-padding) IPv4
+// packet of totalLen bytes addressed to dst.
+func buildIPv4Packet(dst net.IP, totalLen int) []byte {
+	packet := make([]byte, totalLen)
+	packet[0] = 0x45 // version 4, IHL 5 (20-byte header)
+	packet[2] = byte(totalLen >> 8)
+	packet[3] = byte(totalLen)
+	copy(packet[IPv4offsetDst:IPv4offsetDst+net.IPv4len], dst.To4())
+	return packet
+}
+
+// buildIPv6Packet returns a minimal IPv6 packet (40-byte header plus a
+// payloadLen-byte payload) addressed to dst.
+func buildIPv6Packet(dst net.IP, payloadLen int) []byte {
+	packet := make([]byte, 40+payloadLen)
+	packet[0] = 0x60 // version 6
+	packet[4] = byte(payloadLen >> 8)
+	packet[5] = byte(payloadLen)
+	copy(packet[IPv6offsetDst:IPv6offsetDst+net.IPv6len], dst.To16())
+	return packet
+}
+
+func TestSegmentPacketLengthIPv4(t *testing.T) {
+	packet := buildIPv4Packet(net.IPv4(10, 0, 0, 1), 24)
+	if got := segmentPacketLength(packet); got != 24 {
+		t.Fatalf("segmentPacketLength() = %d, want 24", got)
+	}
+}
+
+func TestSegmentPacketLengthIPv6(t *testing.T) {
+	packet := buildIPv6Packet(net.ParseIP("2001:db8::1"), 8)
+	if got := segmentPacketLength(packet); got != 48 {
+		t.Fatalf("segmentPacketLength() = %d, want 48 (40-byte header + 8-byte payload)", got)
+	}
+}
+
+func TestSegmentPacketLengthTruncatedHeader(t *testing.T) {
+	packet := buildIPv4Packet(net.IPv4(10, 0, 0, 1), 24)[:10] // shorter than ipv4.HeaderLen
+	if got := segmentPacketLength(packet); got != 0 {
+		t.Fatalf("segmentPacketLength() = %d, want 0 for a truncated header", got)
+	}
+}
+
+func TestSegmentPacketLengthUnknownVersion(t *testing.T) {
+	packet := buildIPv4Packet(net.IPv4(10, 0, 0, 1), 24)
+	packet[0] = 0x05 // version 0, neither IPv4 nor IPv6
+	if got := segmentPacketLength(packet); got != 0 {
+		t.Fatalf("segmentPacketLength() = %d, want 0 for an unknown IP version", got)
+	}
+}
+
+// newRoutablePeer builds a Peer with the queue/signal fields routePacket
+// touches and registers it in device's IPv4 routing table under dst.
+func newRoutablePeer(device *Device, dst net.IP) *Peer {
+	peer := &Peer{
+		queue: peerQueue{
+			nonce:    make(chan *QueueOutboundElement, QueueOutboundSize),
+			outbound: make(chan *QueueOutboundElement, QueueOutboundSize),
+		},
+		signal: peerSignal{
+			handshakeReset: make(chan struct{}, 1),
+		},
+	}
+
+	if device.routingTable.ipv4 == nil {
+		device.routingTable.ipv4 = make(map[string]*Peer)
+	}
+	device.routingTable.ipv4[string(dst.To4())] = peer
+	return peer
+}
+
+func TestReadSegmentedBufSplitsMultiplePackets(t *testing.T) {
+	device := &Device{}
+	dst := net.IPv4(10, 0, 0, 1)
+	peer := newRoutablePeer(device, dst)
+
+	var buf []byte
+	buf = append(buf, buildIPv4Packet(dst, 24)...)
+	buf = append(buf, buildIPv4Packet(dst, 20)...)
+
+	device.readSegmentedBuf(buf)
+
+	if got := len(peer.queue.nonce); got != 2 {
+		t.Fatalf("peer.queue.nonce has %d elements, want 2 (one per segment)", got)
+	}
+}
+
+func TestReadSegmentedBufStopsAtGarbageSegment(t *testing.T) {
+	device := &Device{}
+	dst := net.IPv4(10, 0, 0, 1)
+	peer := newRoutablePeer(device, dst)
+
+	var buf []byte
+	buf = append(buf, buildIPv4Packet(dst, 24)...)
+	buf = append(buf, 0xFF, 0xFF, 0xFF) // garbage trailing bytes, no valid header
+
+	device.readSegmentedBuf(buf)
+
+	if got := len(peer.queue.nonce); got != 1 {
+		t.Fatalf("peer.queue.nonce has %d elements, want 1 (only the valid leading segment)", got)
+	}
+}
+
+func TestReadSegmentedBufCapsAtMaxSegmentsPerRead(t *testing.T) {
+	device := &Device{}
+	dst := net.IPv4(10, 0, 0, 1)
+	peer := newRoutablePeer(device, dst)
+
+	var buf []byte
+	for i := 0; i < maxSegmentsPerRead+5; i++ {
+		buf = append(buf, buildIPv4Packet(dst, 20)...)
+	}
+
+	device.readSegmentedBuf(buf)
+
+	if got := len(peer.queue.nonce); got != maxSegmentsPerRead {
+		t.Fatalf("peer.queue.nonce has %d elements, want %d (capped)", got, maxSegmentsPerRead)
+	}
+}
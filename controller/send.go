@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	mrand "math/rand"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -37,13 +38,76 @@ import (
  * (to allow the construction of transport messages in-place)
  */
 type QueueOutboundElement struct {
-	dropped int32
-	mutex   sync.Mutex
-	buffer  *[MaxMessageSize]byte // slice holding the packet data
-	packet  []byte                // slice of "buffer" (always!)
-	nonce   uint64                // nonce for encryption
-	keyPair *KeyPair              // key-pair for encryption
-	peer    *Peer                 // related peer
+	dropped  int32
+	released int32 // AtomicTrue once buffer has been returned to the pool
+	mutex    sync.Mutex
+	buffer   *[MaxMessageSize]byte // slice holding the packet data
+	packet   []byte                // slice of "buffer" (always!)
+	nonce    uint64                // nonce for encryption
+	keyPair  *KeyPair              // key-pair for encryption
+	peer     *Peer                 // related peer
+}
+
+// release returns elem's buffer to device's pool exactly once. An element
+// can be reachable from more than one queue at a time (it sits in both
+// peer.queue.outbound and device.queue.encryption between RoutineNonce and
+// RoutineEncryption), so without this guard an eviction from one queue and
+// a drop from the other could both return the same buffer to the pool.
+func (elem *QueueOutboundElement) release(device *Device) {
+	if atomic.CompareAndSwapInt32(&elem.released, AtomicFalse, AtomicTrue) {
+		device.PutMessageBuffer(elem.buffer)
+	}
+}
+
+// SendKeepAlive enqueues a keepalive: an outbound element with an empty
+// payload that still flows through nonce assignment and encryption,
+// sealing down to exactly MessageKeepaliveSize on the wire.
+func (peer *Peer) SendKeepAlive() error {
+	device := peer.device
+	if device == nil {
+		return errors.New("No device for peer")
+	}
+
+	elem := device.NewOutboundElement()
+	elem.packet = elem.buffer[MessageTransportHeaderSize:MessageTransportHeaderSize]
+
+	addToOutboundQueue(device, peer.queue.nonce, elem, &device.stats.droppedNonceQueue)
+	return nil
+}
+
+/* Periodically sends a keepalive so NAT/firewall state stays open even
+ * when there is no outbound data traffic.
+ *
+ * Obs. Single instance per peer. A PersistentKeepaliveInterval of 0
+ * disables the timer.
+ */
+func (peer *Peer) RoutinePersistentKeepAlive() {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Wlog.SaveErrLog(fmt.Sprintln("recover RoutinePersistentKeepAlive err:", err))
+		}
+	}()
+
+	for {
+		interval := atomic.LoadUint32(&peer.persistentKeepaliveInterval)
+		if interval == 0 {
+			select {
+			case <-peer.signal.stop:
+				return
+			case <-time.After(time.Second):
+				continue
+			}
+		}
+
+		select {
+		case <-peer.signal.stop:
+			return
+		case <-time.After(time.Duration(interval) * time.Second):
+			if err := peer.SendKeepAlive(); err != nil {
+				logger.Wlog.SaveDebugLog("Failed to send persistent keepalive:" + err.Error())
+			}
+		}
+	}
 }
 
 func (peer *Peer) FlushNonceQueue() {
@@ -58,9 +122,18 @@ func (peer *Peer) FlushNonceQueue() {
 }
 
 func (device *Device) NewOutboundElement() *QueueOutboundElement {
+	// device.pool.messageBuffers has no New func, so Get returns a nil
+	// interface once the pool is empty (e.g. before anything has ever been
+	// released into it); fall back to allocating a fresh buffer rather than
+	// asserting on nil.
+	buffer, _ := device.pool.messageBuffers.Get().(*[MaxMessageSize]byte)
+	if buffer == nil {
+		buffer = new([MaxMessageSize]byte)
+	}
+
 	return &QueueOutboundElement{
 		dropped: AtomicFalse,
-		buffer:  device.pool.messageBuffers.Get().(*[MaxMessageSize]byte),
+		buffer:  buffer,
 	}
 }
 
@@ -72,7 +145,27 @@ func (elem *QueueOutboundElement) IsDropped() bool {
 	return atomic.LoadInt32(&elem.dropped) == AtomicTrue
 }
 
-func addToOutboundQueue(queue chan *QueueOutboundElement, element *QueueOutboundElement) {
+// DeviceStats is a point-in-time snapshot of the send pipeline's
+// backpressure and error counters, so operators can see when the pipeline
+// is shedding load rather than silently losing throughput.
+type DeviceStats struct {
+	DroppedNonceQueue      uint64
+	DroppedEncryptionQueue uint64
+	DroppedOutboundQueue   uint64
+	SendErrors             uint64
+}
+
+// Stats returns a snapshot of the device's pipeline counters.
+func (device *Device) Stats() DeviceStats {
+	return DeviceStats{
+		DroppedNonceQueue:      atomic.LoadUint64(&device.stats.droppedNonceQueue),
+		DroppedEncryptionQueue: atomic.LoadUint64(&device.stats.droppedEncryptionQueue),
+		DroppedOutboundQueue:   atomic.LoadUint64(&device.stats.droppedOutboundQueue),
+		SendErrors:             atomic.LoadUint64(&device.stats.sendErrors),
+	}
+}
+
+func addToOutboundQueue(device *Device, queue chan *QueueOutboundElement, element *QueueOutboundElement, dropped *uint64) {
 	for {
 		select {
 		case queue <- element:
@@ -80,14 +173,53 @@ func addToOutboundQueue(queue chan *QueueOutboundElement, element *QueueOutbound
 		default:
 			select {
 			case old := <-queue:
+				// old may still be locked by RoutineEncryption writing into
+				// its buffer; wait for that to finish before releasing the
+				// buffer back to the pool, or we'd free it out from under
+				// the in-flight write.
+				old.mutex.Lock()
 				old.Drop()
+				old.release(device)
+				atomic.AddUint64(dropped, 1)
 			default:
 			}
 		}
 	}
 }
 
-func addToEncryptionQueue(queue chan *QueueOutboundElement, element *QueueOutboundElement) {
+// PeerEndpoint is the last known network location of a peer: the
+// destination to send packets to, and (optionally) the local source
+// address/interface the peer most recently reached us on. Pinning Src
+// lets replies leave through the same local address on multihomed hosts,
+// which is required when reverse-path filtering would otherwise drop
+// them.
+type PeerEndpoint struct {
+	Dst        net.UDPAddr
+	Src        net.IP
+	SrcIfIndex int
+}
+
+// setEndpointFromSource updates the peer's endpoint to match where a
+// successfully decrypted packet arrived from, so the peer can roam across
+// networks without requiring a new handshake. Called from
+// handleDecryptedPacket once a transport packet has authenticated.
+func (peer *Peer) setEndpointFromSource(dst *net.UDPAddr, cm4 *ipv4.ControlMessage, cm6 *ipv6.ControlMessage) {
+	endpoint := &PeerEndpoint{Dst: *dst}
+	switch {
+	case cm4 != nil:
+		endpoint.Src = cm4.Src
+		endpoint.SrcIfIndex = cm4.IfIndex
+	case cm6 != nil:
+		endpoint.Src = cm6.Src
+		endpoint.SrcIfIndex = cm6.IfIndex
+	}
+
+	peer.mutex.Lock()
+	peer.endpoint = endpoint
+	peer.mutex.Unlock()
+}
+
+func addToEncryptionQueue(device *Device, queue chan *QueueOutboundElement, element *QueueOutboundElement, dropped *uint64) {
 	for {
 		select {
 		case queue <- element:
@@ -98,27 +230,84 @@ func addToEncryptionQueue(queue chan *QueueOutboundElement, element *QueueOutbou
 				// drop & release to potential consumer
 				old.Drop()
 				old.mutex.Unlock()
+				old.release(device)
+				atomic.AddUint64(dropped, 1)
 			default:
 			}
 		}
 	}
 }
 
-func (peer *Peer) SendBuffer(buffer []byte) (int, error) {
-	peer.device.net.mutex.RLock()
-	defer peer.device.net.mutex.RUnlock()
+// writeTo sends buffer to endpoint over device's socket. Callers must
+// already hold device.net.mutex for reading; it never takes the lock
+// itself so it is safe to call from sendBatch's fallback path.
+func writeTo(device *Device, endpoint *PeerEndpoint, buffer []byte) (int, error) {
+	switch conn := device.net.conn.(type) {
+	case nil:
+		return 0, errors.New("No UDP socket for device")
 
-	peer.mutex.RLock()
-	defer peer.mutex.RUnlock()
+	case *ipv4.PacketConn:
+		cm := &ipv4.ControlMessage{IfIndex: endpoint.SrcIfIndex}
+		if endpoint.Src != nil {
+			cm.Src = endpoint.Src
+		}
+		return conn.WriteTo(buffer, cm, &endpoint.Dst)
 
-	conn := peer.device.net.conn
-	if conn == nil {
-		return 0, errors.New("No UDP socket for device")
+	case *ipv6.PacketConn:
+		cm := &ipv6.ControlMessage{IfIndex: endpoint.SrcIfIndex}
+		if endpoint.Src != nil {
+			cm.Src = endpoint.Src
+		}
+		return conn.WriteTo(buffer, cm, &endpoint.Dst)
+
+	default:
+		return 0, errors.New("Unsupported UDP socket type for device")
 	}
+}
 
-	n, err := conn.Write(buffer)
+// routePacket looks up the peer elem.packet is destined for and, if found,
+// inserts elem into that peer's nonce/pre-handshake queue. It reports
+// whether elem was consumed; on false elem stays the caller's
+// (RoutineReadFromTUN reuses it for the next packet, readSegmentedBuf
+// releases it).
+func (device *Device) routePacket(elem *QueueOutboundElement) bool {
+	// lookup peer
+
+	//if IsCallbackIp == 1 {
+	//	desIp := elem.packet[16:20]
+	//	ipstr := net.IPv4(desIp[0], desIp[1], desIp[2], desIp[3]).String()
+	//	go sendDestinationIP(ipstr)
+	//}
+
+	var peer *Peer
+	switch elem.packet[0] >> 4 {
+	case ipv4.Version:
+		if len(elem.packet) < ipv4.HeaderLen {
+			return false
+		}
+		dst := elem.packet[IPv4offsetDst : IPv4offsetDst+net.IPv4len]
+		peer = device.routingTable.LookupIPv4(dst)
+
+	case ipv6.Version:
+		if len(elem.packet) < ipv6.HeaderLen {
+			return false
+		}
+		dst := elem.packet[IPv6offsetDst : IPv6offsetDst+net.IPv6len]
+		peer = device.routingTable.LookupIPv6(dst)
 
-	return n, err
+	default:
+		logger.Wlog.SaveDebugLog(fmt.Sprintln("Receieved packet with unknown IP version:", len(elem.packet),
+			elem.packet[0]>>4, hex.EncodeToString(elem.packet)))
+	}
+
+	if peer == nil {
+		return false
+	}
+
+	// insert into nonce/pre-handshake queue
+	signalSend(peer.signal.handshakeReset)
+	addToOutboundQueue(device, peer.queue.nonce, elem, &device.stats.droppedNonceQueue)
+	return true
 }
 
 /* Reads packets from the TUN and inserts
@@ -133,10 +322,15 @@ func (device *Device) RoutineReadFromTUN() {
 		}
 	}()
 
-	elem := device.NewOutboundElement()
-
 	logger.Wlog.SaveDebugLog("Routine, TUN Reader started")
 
+	if device.tun.segmentedReads {
+		device.routineReadSegmentedFromTUN()
+		return
+	}
+
+	elem := device.NewOutboundElement()
+
 	for {
 		select {
 		case <-device.signal.stop:
@@ -146,58 +340,106 @@ func (device *Device) RoutineReadFromTUN() {
 		default:
 			// read packet
 			elem.packet = elem.buffer[MessageTransportHeaderSize:]
-			recvPacket, err := device.tun.device.Read(elem.packet)
+			n, err := device.tun.device.Read(elem.packet)
 			if err != nil {
 				logger.Wlog.SaveErrLog("Failed to read packet from TUN device:" + err.Error())
 				sendStatus(101)
 				return
 			}
 
-			length := len(recvPacket)
-
-			if length == 0 || length > MaxContentSize {
+			if n == 0 || n > MaxContentSize {
 				continue
 			}
 
-			elem.packet = recvPacket
+			elem.packet = elem.packet[:n]
 
-			// lookup peer
+			if device.routePacket(elem) {
+				elem = device.NewOutboundElement()
+			}
+		}
+	}
+}
 
-			//if IsCallbackIp == 1 {
-			//	desIp := elem.packet[16:20]
-			//	ipstr := net.IPv4(desIp[0], desIp[1], desIp[2], desIp[3]).String()
-			//	go sendDestinationIP(ipstr)
-			//}
+// segmentedReadScratchSize bounds the scratch buffer used for segmented
+// (GRO-style) TUN reads.
+const segmentedReadScratchSize = 1 << 16
 
-			var peer *Peer
-			switch elem.packet[0] >> 4 {
-			case ipv4.Version:
-				if len(elem.packet) < ipv4.HeaderLen {
-					continue
-				}
-				dst := elem.packet[IPv4offsetDst : IPv4offsetDst+net.IPv4len]
-				peer = device.routingTable.LookupIPv4(dst)
+// maxSegmentsPerRead caps how many packets a single segmented TUN read may
+// be split into, protecting the outbound element pool from a single
+// pathological read.
+const maxSegmentsPerRead = 64
 
-			case ipv6.Version:
-				if len(elem.packet) < ipv6.HeaderLen {
-					continue
-				}
-				dst := elem.packet[IPv6offsetDst : IPv6offsetDst+net.IPv6len]
-				peer = device.routingTable.LookupIPv6(dst)
+// routineReadSegmentedFromTUN is the segmented-read counterpart of
+// RoutineReadFromTUN: it issues one larger Read and splits the result into
+// individual IP packets by walking successive IPv4/IPv6 headers, trading
+// one syscall for many packets on TUN drivers that support returning more
+// than one packet per read (device.tun.segmentedReads). Drivers that only
+// ever return a single packet per read must leave that flag unset.
+func (device *Device) routineReadSegmentedFromTUN() {
+	scratch := make([]byte, segmentedReadScratchSize)
 
-			default:
-				logger.Wlog.SaveDebugLog(fmt.Sprintln("Receieved packet with unknown IP version:", len(elem.packet),
-					elem.packet[0]>>4, hex.EncodeToString(elem.packet)))
-			}
+	for {
+		select {
+		case <-device.signal.stop:
+			logger.Wlog.SaveDebugLog("Routine, TUN Reader worker, stopped")
+			return
 
-			if peer == nil {
-				continue
+		default:
+			n, err := device.tun.device.Read(scratch)
+			if err != nil {
+				logger.Wlog.SaveErrLog("Failed to read packet from TUN device:" + err.Error())
+				sendStatus(101)
+				return
 			}
 
-			// insert into nonce/pre-handshake queue
-			signalSend(peer.signal.handshakeReset)
-			addToOutboundQueue(peer.queue.nonce, elem)
-			elem = device.NewOutboundElement()
+			device.readSegmentedBuf(scratch[:n])
+		}
+	}
+}
+
+// segmentPacketLength returns the length of the IP packet starting at
+// buf, as declared by its IPv4/IPv6 header, or 0 if buf is too short to
+// hold that header or its IP version is neither 4 nor 6.
+func segmentPacketLength(buf []byte) int {
+	switch buf[0] >> 4 {
+	case ipv4.Version:
+		if len(buf) >= ipv4.HeaderLen {
+			return int(binary.BigEndian.Uint16(buf[2:4]))
+		}
+
+	case ipv6.Version:
+		if len(buf) >= ipv6.HeaderLen {
+			return ipv6.HeaderLen + int(binary.BigEndian.Uint16(buf[4:6]))
+		}
+
+	default:
+		logger.Wlog.SaveDebugLog(fmt.Sprintln("Received packet with unknown IP version in segmented TUN read:",
+			buf[0]>>4, hex.EncodeToString(buf)))
+	}
+
+	return 0
+}
+
+// readSegmentedBuf walks buf splitting it into individual IP packets by
+// successive header lengths, routing each to its peer. It stops early at
+// a truncated/garbage header (whatever precedes it has already been
+// routed) or after maxSegmentsPerRead segments.
+func (device *Device) readSegmentedBuf(buf []byte) {
+	for segments := 0; len(buf) > 0 && segments < maxSegmentsPerRead; segments++ {
+		packetLen := segmentPacketLength(buf)
+		if packetLen <= 0 || packetLen > len(buf) || packetLen > MaxContentSize {
+			break
+		}
+
+		segment := buf[:packetLen]
+		buf = buf[packetLen:]
+
+		elem := device.NewOutboundElement()
+		elem.packet = elem.buffer[MessageTransportHeaderSize : MessageTransportHeaderSize+len(segment)]
+		copy(elem.packet, segment)
+
+		if !device.routePacket(elem) {
+			elem.release(device)
 		}
 	}
 }
@@ -242,6 +484,12 @@ func (peer *Peer) RoutineNonce() {
 
 				select {
 				case <-peer.signal.newKeyPair:
+					// nothing was queued to ride the fresh key pair out
+					// immediately, so the initiator still has to push
+					// something to complete the handshake
+					if len(peer.queue.nonce) == 0 {
+						peer.SendKeepAlive()
+					}
 				case <-peer.signal.flushNonceQueue:
 					logger.Wlog.SaveDebugLog("Clearing queue for" + peer.String())
 					peer.FlushNonceQueue()
@@ -259,8 +507,8 @@ func (peer *Peer) RoutineNonce() {
 			elem.mutex.Lock()
 
 			// add to parallel and sequential queue
-			addToEncryptionQueue(device.queue.encryption, elem)
-			addToOutboundQueue(peer.queue.outbound, elem)
+			addToEncryptionQueue(device, device.queue.encryption, elem, &device.stats.droppedEncryptionQueue)
+			addToOutboundQueue(device, peer.queue.outbound, elem, &device.stats.droppedOutboundQueue)
 		}
 	}
 }
@@ -329,6 +577,175 @@ func (device *Device) RoutineEncryption() {
 	}
 }
 
+// sendBatch dispatches elems to the peer's endpoint in as few syscalls as
+// possible, using sendmmsg(2) via ipv4.PacketConn.WriteBatch/
+// ipv6.PacketConn.WriteBatch where the underlying socket supports it, and
+// falling back to one write per element otherwise. It returns the number
+// of elements actually sent; the remainder is left to the caller
+// (dispatchBatch re-queues it whether or not err is set).
+func (peer *Peer) sendBatch(elems []*QueueOutboundElement) (int, error) {
+	device := peer.device
+
+	device.net.mutex.RLock()
+	defer device.net.mutex.RUnlock()
+
+	peer.mutex.RLock()
+	endpoint := peer.endpoint
+	peer.mutex.RUnlock()
+
+	if endpoint == nil {
+		return 0, errors.New("No known endpoint for peer")
+	}
+
+	var sent int
+	var err error
+
+	switch conn := device.net.conn.(type) {
+	case *ipv4.PacketConn:
+		var oob []byte
+		if endpoint.Src != nil {
+			oob = (&ipv4.ControlMessage{Src: endpoint.Src, IfIndex: endpoint.SrcIfIndex}).Marshal()
+		}
+
+		msgs := make([]ipv4.Message, len(elems))
+		for i, elem := range elems {
+			msgs[i].Buffers = [][]byte{elem.packet}
+			msgs[i].Addr = &endpoint.Dst
+			msgs[i].OOB = oob
+		}
+
+		for sent < len(msgs) {
+			var n int
+			n, err = conn.WriteBatch(msgs[sent:], 0)
+			// On Linux, sendmmsg(2) returns -1 (not 0) when the first
+			// message in this call fails outright, so guard against that
+			// rather than letting sent go backwards.
+			if n > 0 {
+				sent += n
+			}
+			if err != nil || n <= 0 {
+				break
+			}
+		}
+
+		atomic.AddUint64(&peer.stats.sendmmsgSegments, uint64(sent))
+
+	case *ipv6.PacketConn:
+		var oob []byte
+		if endpoint.Src != nil {
+			oob = (&ipv6.ControlMessage{Src: endpoint.Src, IfIndex: endpoint.SrcIfIndex}).Marshal()
+		}
+
+		msgs := make([]ipv6.Message, len(elems))
+		for i, elem := range elems {
+			msgs[i].Buffers = [][]byte{elem.packet}
+			msgs[i].Addr = &endpoint.Dst
+			msgs[i].OOB = oob
+		}
+
+		for sent < len(msgs) {
+			var n int
+			n, err = conn.WriteBatch(msgs[sent:], 0)
+			// On Linux, sendmmsg(2) returns -1 (not 0) when the first
+			// message in this call fails outright, so guard against that
+			// rather than letting sent go backwards.
+			if n > 0 {
+				sent += n
+			}
+			if err != nil || n <= 0 {
+				break
+			}
+		}
+
+		atomic.AddUint64(&peer.stats.sendmmsgSegments, uint64(sent))
+
+	default:
+		// no batch support on this socket type, fall back to one send per
+		// element; this path never calls sendmmsg, so it doesn't count
+		// toward sendmmsgSegments.
+		for _, elem := range elems {
+			var werr error
+			if _, werr = writeTo(device, endpoint, elem.packet); werr != nil {
+				err = werr
+				break
+			}
+			sent++
+		}
+	}
+
+	return sent, err
+}
+
+const (
+	minSendBackoff = 250 * time.Millisecond
+	maxSendBackoff = 8 * time.Second
+)
+
+// peerSendBackoff tracks exponential backoff state for a peer's outbound
+// sender, so a run of send errors can't block the per-peer sender
+// goroutine or hammer endpoint re-resolution.
+type peerSendBackoff struct {
+	mutex   sync.Mutex
+	current time.Duration
+	cycling bool
+}
+
+// jitter returns d adjusted by up to +-25%.
+func jitter(d time.Duration) time.Duration {
+	delta := time.Duration(mrand.Int63n(int64(d))) - d/2
+	return d + delta/2
+}
+
+// onSendError marks the peer unhealthy and, unless a backoff cycle is
+// already in flight, schedules an asynchronous endpoint re-resolution
+// with exponential backoff (250ms -> 8s, capped) and jitter. The sender
+// goroutine itself is never blocked, so a flapping peer only bounds
+// head-of-line blocking rather than stalling it outright. The handshake
+// is reset once per backoff cycle, not once per dropped packet.
+func (peer *Peer) onSendError() {
+	backoff := &peer.sendBackoff
+
+	backoff.mutex.Lock()
+	if backoff.cycling {
+		backoff.mutex.Unlock()
+		return
+	}
+	backoff.cycling = true
+
+	if backoff.current == 0 {
+		backoff.current = minSendBackoff
+	} else {
+		backoff.current *= 2
+		if backoff.current > maxSendBackoff {
+			backoff.current = maxSendBackoff
+		}
+	}
+	delay := jitter(backoff.current)
+	backoff.mutex.Unlock()
+
+	signalSend(peer.signal.handshakeReset)
+
+	time.AfterFunc(delay, func() {
+		changeNetwork(peer.device, Endpoint)
+
+		backoff.mutex.Lock()
+		backoff.cycling = false
+		backoff.mutex.Unlock()
+	})
+}
+
+// onSendSuccess resets the backoff once a send goes through again, clearing
+// cycling too so a stale pending timer doesn't suppress the next failure's
+// backoff cycle.
+func (peer *Peer) onSendSuccess() {
+	backoff := &peer.sendBackoff
+
+	backoff.mutex.Lock()
+	backoff.current = 0
+	backoff.cycling = false
+	backoff.mutex.Unlock()
+}
+
 /* Sequentially reads packets from queue and sends to endpoint
  *
  * Obs. Single instance per peer.
@@ -346,7 +763,13 @@ func (peer *Peer) RoutineSequentialSender() {
 
 	logger.Wlog.SaveDebugLog("Routine, sequential sender, started")
 
+	batchSize := device.BatchSize()
+	batch := make([]*QueueOutboundElement, 0, batchSize)
+
 	for {
+		batch = batch[:0]
+		batchSize = device.BatchSize()
+
 		select {
 		case <-peer.signal.stop:
 			logger.Wlog.SaveDebugLog("Routine, sequential sender, stopped for" + peer.String())
@@ -356,30 +779,91 @@ func (peer *Peer) RoutineSequentialSender() {
 			if !ok {
 				return
 			}
+			batch = append(batch, elem)
+		}
+
+		// opportunistically coalesce any further elements that are already
+		// ready, without blocking for more of them
+	drain:
+		for len(batch) < batchSize {
+			select {
+			case elem, ok := <-peer.queue.outbound:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, elem)
+			default:
+				break drain
+			}
+		}
+
+		ready := batch[:0:0]
+		for _, elem := range batch {
 			elem.mutex.Lock()
 			if elem.IsDropped() {
+				elem.release(device)
 				continue
 			}
+			ready = append(ready, elem)
+		}
 
-			// send message and return buffer to pool
-			_, err := peer.SendBuffer(elem.packet)
-			device.PutMessageBuffer(elem.buffer)
-			if err != nil {
-				logger.Wlog.SaveDebugLog("Failed to send authenticated packet to peer:" + err.Error())
-				time.Sleep(2 * time.Second)
-				changeNetwork(peer.device, Endpoint)
+		if len(ready) == 0 {
+			continue
+		}
 
-				continue
-			}
+		peer.dispatchBatch(ready)
+	}
+}
 
-			//UploadFlowNum += n
+// dispatchBatch sends ready via sendBatch and settles each element:
+// released once actually sent, re-queued onto peer.queue.outbound if it
+// was not sent (after a short write, or when an error aborted the batch:
+// the element that failed and every element behind it), and the backoff/
+// error-rate bookkeeping updated to match. Callers must hold each
+// elem.mutex locked on entry (as RoutineSequentialSender does after
+// RoutineEncryption releases it).
+func (peer *Peer) dispatchBatch(ready []*QueueOutboundElement) {
+	device := peer.device
 
-			// update timers
-			peer.TimerAnyAuthenticatedPacketTraversal()
-			if len(elem.packet) != MessageKeepaliveSize {
-				peer.TimerDataSent()
-			}
-			peer.KeepKeyFreshSending()
+	sent, err := peer.sendBatch(ready)
+	for _, elem := range ready[:sent] {
+		elem.release(device)
+	}
+
+	if err != nil {
+		logger.Wlog.SaveDebugLog("Failed to send authenticated packet to peer:" + err.Error())
+		atomic.AddUint64(&device.stats.sendErrors, 1)
+
+		// sendBatch stops at the first packet that fails; that packet and
+		// the untried tail behind it are re-queued, not dropped, the same
+		// as after a successful short write.
+		for _, elem := range ready[sent:] {
+			elem.mutex.Unlock()
+			addToOutboundQueue(device, peer.queue.outbound, elem, &device.stats.droppedOutboundQueue)
+		}
+		peer.onSendError()
+
+		return
+	}
+
+	peer.onSendSuccess()
+
+	if sent < len(ready) {
+		// short write: re-queue the tail for another attempt
+		for _, elem := range ready[sent:] {
+			elem.mutex.Unlock()
+			addToOutboundQueue(device, peer.queue.outbound, elem, &device.stats.droppedOutboundQueue)
+		}
+	}
+
+	//UploadFlowNum += n
+
+	// update timers
+	for _, elem := range ready[:sent] {
+		peer.TimerAnyAuthenticatedPacketTraversal()
+		if len(elem.packet) != MessageKeepaliveSize {
+			peer.TimerDataSent()
 		}
 	}
+	peer.KeepKeyFreshSending()
 }
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterStaysWithinQuarterBounds(t *testing.T) {
+	const d = 4 * time.Second
+	for i := 0; i < 1000; i++ {
+		got := jitter(d)
+		if got < d-d/4 || got > d+d/4 {
+			t.Fatalf("jitter(%v) = %v, want within +-25%% of %v", d, got, d)
+		}
+	}
+}
+
+func newBackoffTestPeer() *Peer {
+	peer := &Peer{device: &Device{}}
+	peer.signal.handshakeReset = make(chan struct{}, 1)
+	return peer
+}
+
+// resetCycling simulates the pending time.AfterFunc scheduled by
+// onSendError having fired, without waiting out the real delay.
+func resetCycling(peer *Peer) {
+	peer.sendBackoff.mutex.Lock()
+	peer.sendBackoff.cycling = false
+	peer.sendBackoff.mutex.Unlock()
+}
+
+func TestOnSendErrorDoublesBackoffUpToCap(t *testing.T) {
+	peer := newBackoffTestPeer()
+
+	want := []time.Duration{
+		minSendBackoff,
+		2 * minSendBackoff,
+		4 * minSendBackoff,
+		8 * minSendBackoff,
+		16 * minSendBackoff,
+		maxSendBackoff, // 250ms * 32 = 8s, already at the cap
+		maxSendBackoff, // stays capped
+	}
+
+	for i, w := range want {
+		peer.onSendError()
+
+		peer.sendBackoff.mutex.Lock()
+		got := peer.sendBackoff.current
+		peer.sendBackoff.mutex.Unlock()
+
+		if got != w {
+			t.Fatalf("after call %d: current = %v, want %v", i+1, got, w)
+		}
+		resetCycling(peer)
+	}
+}
+
+func TestOnSendErrorSuppressesConcurrentCycle(t *testing.T) {
+	peer := newBackoffTestPeer()
+
+	peer.onSendError()
+	peer.sendBackoff.mutex.Lock()
+	first := peer.sendBackoff.current
+	peer.sendBackoff.mutex.Unlock()
+
+	// No resetCycling here: a backoff cycle is still "in flight", so this
+	// call must be a no-op rather than doubling the backoff again.
+	peer.onSendError()
+
+	peer.sendBackoff.mutex.Lock()
+	second := peer.sendBackoff.current
+	peer.sendBackoff.mutex.Unlock()
+
+	if second != first {
+		t.Fatalf("current changed from %v to %v while a backoff cycle was still in flight", first, second)
+	}
+}
+
+func TestOnSendSuccessResetsBackoff(t *testing.T) {
+	peer := newBackoffTestPeer()
+
+	peer.onSendError()
+	peer.onSendSuccess()
+
+	peer.sendBackoff.mutex.Lock()
+	current := peer.sendBackoff.current
+	cycling := peer.sendBackoff.cycling
+	peer.sendBackoff.mutex.Unlock()
+
+	if current != 0 {
+		t.Fatalf("current = %v, want 0 after onSendSuccess", current)
+	}
+	if cycling {
+		t.Fatal("cycling = true, want false after onSendSuccess — a stale pending timer would suppress the next failure's backoff")
+	}
+
+	// A fresh failure after a reset must start back at the minimum delay,
+	// not continue doubling from where it left off.
+	peer.onSendError()
+	peer.sendBackoff.mutex.Lock()
+	current = peer.sendBackoff.current
+	peer.sendBackoff.mutex.Unlock()
+
+	if current != minSendBackoff {
+		t.Fatalf("current after reset = %v, want %v", current, minSendBackoff)
+	}
+}
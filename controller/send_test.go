@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"golang.org/x/net/ipv4"
+)
+
+func TestQueueOutboundElementReleaseOnce(t *testing.T) {
+	device := &Device{}
+	elem := device.NewOutboundElement()
+	buf := elem.buffer
+
+	elem.release(device)
+	elem.release(device) // second release must be a no-op, not a double Put
+
+	got, _ := device.pool.messageBuffers.Get().(*[MaxMessageSize]byte)
+	if got != buf {
+		t.Fatalf("expected the released buffer back from the pool, got %v", got)
+	}
+	if extra, ok := device.pool.messageBuffers.Get().(*[MaxMessageSize]byte); ok {
+		t.Fatalf("pool held a second buffer (%v) — release() returned it to the pool twice", extra)
+	}
+}
+
+func TestNewPeerSeedsConfiguredEndpoint(t *testing.T) {
+	device := &Device{}
+	addr := &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51820}
+
+	peer := device.NewPeer(addr)
+
+	peer.mutex.RLock()
+	endpoint := peer.endpoint
+	peer.mutex.RUnlock()
+
+	if endpoint == nil {
+		t.Fatal("expected NewPeer to seed peer.endpoint from the configured address")
+	}
+	if endpoint.Dst.String() != addr.String() {
+		t.Fatalf("endpoint.Dst = %v, want %v", endpoint.Dst.String(), addr.String())
+	}
+}
+
+func TestNewPeerWithoutEndpointLeavesItUnset(t *testing.T) {
+	device := &Device{}
+	peer := device.NewPeer(nil)
+
+	if peer.endpoint != nil {
+		t.Fatal("expected endpoint to stay unset until learned via setEndpointFromSource")
+	}
+}
+
+// TestSendBatchPartialWriteReturnsCountAndError exercises sendBatch's
+// WriteBatch retry loop: sendmmsg(2) sends messages in order and stops at
+// the first one that errors, so an oversized packet past a valid one
+// should come back as a short write (sent < len(elems)) with err set,
+// matching what RoutineSequentialSender's requeue path expects.
+func TestSendBatchPartialWriteReturnsCountAndError(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WriteBatch/sendmmsg is Linux-only")
+	}
+
+	rawConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer rawConn.Close()
+
+	dst, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer dst.Close()
+
+	device := &Device{}
+	device.net.conn = ipv4.NewPacketConn(rawConn)
+
+	peer := device.NewPeer(dst.LocalAddr().(*net.UDPAddr))
+
+	ok := device.NewOutboundElement()
+	ok.packet = ok.buffer[:10]
+
+	tooBig := device.NewOutboundElement()
+	tooBig.packet = make([]byte, 1<<16) // exceeds the UDP datagram size limit
+
+	sent, err := peer.sendBatch([]*QueueOutboundElement{ok, tooBig})
+	if err == nil {
+		t.Fatal("expected an error from the oversized second message")
+	}
+	if sent != 1 {
+		t.Fatalf("sent = %d, want 1 (only the first message should have gone out)", sent)
+	}
+	if got := peer.SendmmsgSegments(); got != 1 {
+		t.Fatalf("SendmmsgSegments() = %d, want 1", got)
+	}
+}
+
+// TestDispatchBatchRequeuesUntriedTailOnError reproduces the scenario a
+// partial sendBatch failure leaves behind: only the packets sendBatch
+// actually sent are gone, so the one that errored and the untried tail
+// behind it — even a perfectly good packet — must go back onto
+// peer.queue.outbound for another attempt rather than being dropped.
+func TestDispatchBatchRequeuesUntriedTailOnError(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WriteBatch/sendmmsg is Linux-only")
+	}
+
+	rawConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer rawConn.Close()
+
+	dst, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer dst.Close()
+
+	device := &Device{}
+	device.net.conn = ipv4.NewPacketConn(rawConn)
+
+	peer := device.NewPeer(dst.LocalAddr().(*net.UDPAddr))
+
+	ok := device.NewOutboundElement()
+	ok.packet = ok.buffer[:10]
+
+	tooBig := device.NewOutboundElement()
+	tooBig.packet = make([]byte, 1<<16) // exceeds the UDP datagram size limit
+
+	third := device.NewOutboundElement()
+	third.packet = third.buffer[:10] // never attempted: sendBatch aborts at tooBig
+
+	ready := []*QueueOutboundElement{ok, tooBig, third}
+	for _, elem := range ready {
+		elem.mutex.Lock() // dispatchBatch expects each elem locked on entry
+	}
+
+	peer.dispatchBatch(ready)
+
+	if got := len(peer.queue.outbound); got != 2 {
+		t.Fatalf("peer.queue.outbound has %d elements, want 2 (tooBig and third re-queued)", got)
+	}
+
+	requeued := map[*QueueOutboundElement]bool{}
+	for i := 0; i < 2; i++ {
+		requeued[<-peer.queue.outbound] = true
+	}
+	if !requeued[tooBig] || !requeued[third] {
+		t.Fatal("expected both the failed and the untried packet to be re-queued, not dropped")
+	}
+
+	if got := device.Stats().SendErrors; got != 1 {
+		t.Fatalf("device.Stats().SendErrors = %d, want 1", got)
+	}
+}
@@ -0,0 +1,26 @@
+// Package logger provides the device-wide log sink used throughout bt.
+package logger
+
+import "log"
+
+// wlog is the log sink implementation; Wlog is the package-wide instance
+// other packages call into.
+type wlog struct{}
+
+// Wlog is the shared logger instance.
+var Wlog wlog
+
+// SaveErrLog records an error-level message.
+func (wlog) SaveErrLog(msg string) {
+	log.Println("[ERR]", msg)
+}
+
+// SaveDebugLog records a debug-level message.
+func (wlog) SaveDebugLog(msg string) {
+	log.Println("[DEBUG]", msg)
+}
+
+// SaveInfoLog records an info-level message.
+func (wlog) SaveInfoLog(msg string) {
+	log.Println("[INFO]", msg)
+}